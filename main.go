@@ -4,7 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/mumoshu/helm-x/pkg"
+	"github.com/mumoshu/helm-x/pkg/chartify"
+	"github.com/mumoshu/helm-x/pkg/release"
 	"github.com/spf13/pflag"
 	"io"
 	"log"
@@ -48,16 +49,17 @@ func NewRootCmd() *cobra.Command {
 }
 
 type dumpCmd struct {
-	*x.ClientOpts
+	*chartify.ClientOpts
 
 	TillerNamespace string
+	Namespace       string
 
 	Out io.Writer
 }
 
 // NewApplyCommand represents the apply command
 func NewApplyCommand(out io.Writer, cmdName string, installByDefault bool) *cobra.Command {
-	upOpts := &x.UpgradeOpts{Out: out}
+	upOpts := &chartify.UpgradeOpts{Out: out}
 
 	cmd := &cobra.Command{
 		Use:   fmt.Sprintf("%s [RELEASE] [DIR_OR_CHART]", cmdName),
@@ -86,7 +88,9 @@ When DIR_OR_CHART contains kustomization.yaml, this runs "kustomize build" to ge
 			dir := args[1]
 
 			upOpts.ReleaseName = release
-			tempDir, err := x.Chartify(dir, *upOpts.ChartifyOpts)
+			upOpts.ChartifyOpts.ExtraAnnotations = chartify.ParseKeyValues(upOpts.ReleaseAnnotations)
+			upOpts.ChartifyOpts.ExtraLabels = chartify.ParseKeyValues(upOpts.ReleaseLabels)
+			tempDir, err := chartify.Chartify(dir, *upOpts.ChartifyOpts)
 			if err != nil {
 				cmd.SilenceUsage = true
 				return err
@@ -101,12 +105,19 @@ When DIR_OR_CHART contains kustomization.yaml, this runs "kustomize build" to ge
 			upOpts.Chart = tempDir
 
 			if len(upOpts.Adopt) > 0 {
-				if err := x.Adopt(upOpts.TillerNamespace, release, upOpts.Namespace, upOpts.Adopt); err != nil {
+				adoptOpts := chartify.AdoptOpts{
+					ClientOpts:      upOpts.ClientOpts,
+					Out:             out,
+					Namespace:       upOpts.Namespace,
+					TillerNamespace: upOpts.TillerNamespace,
+					Runner:          upOpts.ChartifyOpts.Runner,
+				}
+				if err := chartify.Adopt(adoptOpts, release, upOpts.Adopt); err != nil {
 					return err
 				}
 			}
 
-			if err := x.Upgrade(*upOpts); err != nil {
+			if err := chartify.Upgrade(*upOpts); err != nil {
 				cmd.SilenceUsage = true
 				return err
 			}
@@ -128,12 +139,15 @@ When DIR_OR_CHART contains kustomization.yaml, this runs "kustomize build" to ge
 
 	f.StringSliceVarP(&upOpts.Adopt, "adopt", "", []string{}, "adopt existing k8s resources before apply")
 
+	f.StringArrayVar(&upOpts.ReleaseAnnotations, "release-annotation", []string{}, "key=value pair recorded as an annotation on the generated chart and helm release, for tracking chartify provenance (can specify multiple)")
+	f.StringArrayVar(&upOpts.ReleaseLabels, "release-label", []string{}, "key=value pair recorded as a label on the generated chart and helm release, for tracking chartify provenance (can specify multiple)")
+
 	return cmd
 }
 
 // NewTemplateCommand represents the template command
 func NewTemplateCommand(out io.Writer) *cobra.Command {
-	templateOpts := &x.TemplateOpts{Out: out}
+	templateOpts := &chartify.TemplateOpts{Out: out}
 
 	cmd := &cobra.Command{
 		Use:   "template [DIR_OR_CHART]",
@@ -157,7 +171,9 @@ When DIR_OR_CHART contains kustomization.yaml, this runs "kustomize build" to ge
 		RunE: func(cmd *cobra.Command, args []string) error {
 			dir := args[0]
 
-			tempDir, err := x.Chartify(dir, *templateOpts.ChartifyOpts)
+			templateOpts.ChartifyOpts.ExtraAnnotations = chartify.ParseKeyValues(templateOpts.ReleaseAnnotations)
+			templateOpts.ChartifyOpts.ExtraLabels = chartify.ParseKeyValues(templateOpts.ReleaseLabels)
+			tempDir, err := chartify.Chartify(dir, *templateOpts.ChartifyOpts)
 			if err != nil {
 				cmd.SilenceUsage = true
 				return err
@@ -168,7 +184,7 @@ When DIR_OR_CHART contains kustomization.yaml, this runs "kustomize build" to ge
 				defer os.RemoveAll(tempDir)
 			}
 
-			if err := x.Template(tempDir, *templateOpts); err != nil {
+			if err := chartify.Template(tempDir, *templateOpts); err != nil {
 				cmd.SilenceUsage = true
 				return err
 			}
@@ -179,18 +195,23 @@ When DIR_OR_CHART contains kustomization.yaml, this runs "kustomize build" to ge
 	f := cmd.Flags()
 
 	templateOpts.ChartifyOpts = chartifyOptsFromFlags(f)
+	templateOpts.ClientOpts = clientOptsFromFlags(f)
 
 	f.StringVar(&templateOpts.ReleaseName, "name", "release-name", "release name (default \"release-name\")")
 	f.StringVar(&templateOpts.TillerNamespace, "tiller-namsepace", "kube-system", "Namespace in which release confgimap/secret objects reside")
 	f.BoolVar(&templateOpts.IncludeReleaseConfigmap, "include-release-configmap", false, "turn the result into a proper helm release, by removing hooks from the manifest, and including a helm release configmap/secret that should otherwise created by \"helm [upgrade|install]\"")
 	f.BoolVar(&templateOpts.IncludeReleaseSecret, "include-release-secret", false, "turn the result into a proper helm release, by removing hooks from the manifest, and including a helm release configmap/secret that should otherwise created by \"helm [upgrade|install]\"")
+	f.BoolVar(&templateOpts.Validate, "validate", false, "submit the rendered manifests to the API server as a server-side dry-run, to catch missing CRDs and admission-webhook rejections early")
+
+	f.StringArrayVar(&templateOpts.ReleaseAnnotations, "release-annotation", []string{}, "key=value pair recorded as an annotation on the generated chart and previewed release object, for tracking chartify provenance (can specify multiple)")
+	f.StringArrayVar(&templateOpts.ReleaseLabels, "release-label", []string{}, "key=value pair recorded as a label on the generated chart and previewed release object, for tracking chartify provenance (can specify multiple)")
 
 	return cmd
 }
 
 // NewDiffCommand represents the diff command
 func NewDiffCommand(out io.Writer) *cobra.Command {
-	diffOpts := &x.DiffOpts{Out: out}
+	diffOpts := &chartify.DiffOpts{Out: out}
 
 	cmd := &cobra.Command{
 		Use:   "diff [RELEASE] [DIR_OR_CHART]",
@@ -216,7 +237,7 @@ When DIR_OR_CHART contains kustomization.yaml, this runs "kustomize build" to ge
 			dir := args[1]
 
 			diffOpts.ReleaseName = release
-			tempDir, err := x.Chartify(dir, *diffOpts.ChartifyOpts)
+			tempDir, err := chartify.Chartify(dir, *diffOpts.ChartifyOpts)
 			if err != nil {
 				cmd.SilenceUsage = true
 				return err
@@ -229,7 +250,7 @@ When DIR_OR_CHART contains kustomization.yaml, this runs "kustomize build" to ge
 
 			diffOpts.Chart = tempDir
 			diffOpts.ReleaseName = release
-			if err := x.Diff(*diffOpts); err != nil {
+			if err := chartify.Diff(*diffOpts); err != nil {
 				cmd.SilenceUsage = true
 				return err
 			}
@@ -249,7 +270,7 @@ When DIR_OR_CHART contains kustomization.yaml, this runs "kustomize build" to ge
 
 // NewAdopt represents the adopt command
 func NewAdopt(out io.Writer) *cobra.Command {
-	adoptOpts := &x.AdoptOpts{Out: out}
+	adoptOpts := &chartify.AdoptOpts{Out: out}
 
 	cmd := &cobra.Command{
 		Use: "adopt [RELEASE] [RESOURCES]...",
@@ -271,10 +292,11 @@ So that the full command looks like:
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			release := args[0]
-			tillerNs := adoptOpts.TillerNamespace
 			resources := args[1:]
 
-			return x.Adopt(tillerNs, release, adoptOpts.Namespace, resources)
+			adoptOpts.Out = out
+
+			return chartify.Adopt(*adoptOpts, release, resources)
 		},
 	}
 	f := cmd.Flags()
@@ -282,6 +304,7 @@ So that the full command looks like:
 	adoptOpts.ClientOpts = clientOptsFromFlags(f)
 
 	f.StringVar(&adoptOpts.Namespace, "namespace", "", "The Namespace in which the resources to be adopted reside")
+	f.StringVar(&adoptOpts.TillerNamespace, "tiller-namespace", "kube-system", "Namespace in which Tiller stores its release ConfigMaps (Helm 2 only; ignored on Helm 3)")
 
 	return cmd
 }
@@ -300,13 +323,13 @@ func NewUtilDumpRelease(out io.Writer) *cobra.Command {
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			release := args[0]
-			storage, err := x.NewConfigMapsStorage(dumpOpts.TillerNamespace)
+			releaseName := args[0]
+			storage, err := release.NewReleaseStorage(dumpOpts.HelmVersion, dumpOpts.TillerNamespace, dumpOpts.Namespace, dumpOpts.KubeContext)
 			if err != nil {
 				return err
 			}
 
-			r, err := storage.GetRelease(release)
+			r, err := storage.GetRelease(releaseName)
 			if err != nil {
 				return err
 			}
@@ -334,11 +357,14 @@ func NewUtilDumpRelease(out io.Writer) *cobra.Command {
 
 	dumpOpts.ClientOpts = clientOptsFromFlags(f)
 
+	f.StringVar(&dumpOpts.TillerNamespace, "tiller-namespace", "kube-system", "Namespace in which Tiller stores its release ConfigMaps (Helm 2 only; ignored on Helm 3)")
+	f.StringVar(&dumpOpts.Namespace, "namespace", "", "Namespace the release lives in (Helm 3 only; ignored on Helm 2)")
+
 	return cmd
 }
 
-func chartifyOptsFromFlags(f *pflag.FlagSet) *x.ChartifyOpts {
-	chartifyOpts := &x.ChartifyOpts{}
+func chartifyOptsFromFlags(f *pflag.FlagSet) *chartify.ChartifyOpts {
+	chartifyOpts := &chartify.ChartifyOpts{}
 
 	f.StringArrayVar(&chartifyOpts.Injectors, "injector", []string{}, "DEPRECATED: Use `--inject \"CMD ARG1 ARG2\"` instead. injector to use (must be pre-installed) and flags to be passed in the syntax of `'CMD SUBCMD,FLAG1=VAL1,FLAG2=VAL2'`. Flags should be without leading \"--\" (can specify multiple). \"FILE\" in values are replaced with the Kubernetes manifest file being injected. Example: \"--injector 'istioctl kube-inject f=FILE,injectConfigFile=inject-config.yaml,meshConfigFile=mesh.config.yaml\"")
 	f.StringArrayVar(&chartifyOpts.Injects, "inject", []string{}, "injector to use (must be pre-installed) and flags to be passed in the syntax of `'istioctl kube-inject -f FILE'`. \"FILE\" is replaced with the Kubernetes manifest file being injected")
@@ -352,16 +378,22 @@ func chartifyOptsFromFlags(f *pflag.FlagSet) *x.ChartifyOpts {
 	f.StringVar(&chartifyOpts.TillerNamespace, "tiller-namespace", "kube-system", "Namespace to in which release configmap/secret objects reside")
 	f.StringVar(&chartifyOpts.ChartVersion, "version", "", "specify the exact chart version to use. If this is not specified, the latest version is used")
 
+	f.BoolVar(&chartifyOpts.BuildDependencies, "build-deps", false, "run \"helm dependency build\" (or \"helm dependency update\" if no lockfile is present) on the chart before templating it")
+
+	f.StringVar(&chartifyOpts.PostRenderer, "post-renderer", "", "path to an executable that the rendered manifests are piped through (after injectors and patches) before being installed")
+	f.StringArrayVar(&chartifyOpts.PostRendererArgs, "post-renderer-arg", []string{}, "argument to pass to --post-renderer (can specify multiple)")
+
 	f.BoolVar(&chartifyOpts.Debug, "debug", false, "enable verbose output")
 
 	return chartifyOpts
 }
 
-func clientOptsFromFlags(f *pflag.FlagSet) *x.ClientOpts {
-	clientOpts := &x.ClientOpts{}
+func clientOptsFromFlags(f *pflag.FlagSet) *chartify.ClientOpts {
+	clientOpts := &chartify.ClientOpts{}
 	f.BoolVar(&clientOpts.TLS, "tls", false, "enable TLS for request")
 	f.StringVar(&clientOpts.TLSCert, "tls-cert", "", "path to TLS certificate file (default: $HELM_HOME/cert.pem)")
 	f.StringVar(&clientOpts.TLSKey, "tls-key", "", "path to TLS key file (default: $HELM_HOME/key.pem)")
 	f.StringVar(&clientOpts.KubeContext, "kubecontext", "", "the kubeconfig context to use")
+	f.StringVar(&clientOpts.HelmVersion, "helm-version", "", "major version of helm to target, \"2\" or \"3\" (default: autodetected from `helm version`)")
 	return clientOpts
 }
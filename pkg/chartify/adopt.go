@@ -0,0 +1,91 @@
+package chartify
+
+import (
+	"fmt"
+
+	"github.com/mumoshu/helm-x/pkg/release"
+
+	"k8s.io/klog"
+)
+
+// Adopt marks each of resources (given as "kind/name") as belonging to
+// releaseName. The annotations/labels applied differ by Helm major
+// version: Helm 3 gets the "meta.helm.sh/release-name" /
+// "meta.helm.sh/release-namespace" annotations Helm itself understands,
+// so a subsequent `helm upgrade --install` won't complain about resources
+// it didn't create. Helm 2 has no ownership metadata convention of its
+// own, so helm-x only adds a label for its own bookkeeping; it does not
+// change how Helm 2 treats the resource.
+func Adopt(opts AdoptOpts, releaseName string, resources []string) error {
+	helmVersion := ""
+	kubeContext := ""
+	if opts.ClientOpts != nil {
+		helmVersion = opts.ClientOpts.HelmVersion
+		kubeContext = opts.ClientOpts.KubeContext
+	}
+	if helmVersion == "" {
+		detected, err := release.DetectHelmVersion()
+		if err != nil {
+			return fmt.Errorf("autodetecting helm version: %w", err)
+		}
+		helmVersion = detected
+	}
+
+	runner := runnerOrDefault(opts.Runner)
+
+	for _, resource := range resources {
+		var err error
+		switch helmVersion {
+		case "3":
+			err = adoptForHelm3(runner, opts.Namespace, kubeContext, releaseName, resource)
+		default:
+			err = adoptForHelm2(runner, opts.TillerNamespace, opts.Namespace, kubeContext, releaseName, resource)
+		}
+		if err != nil {
+			return fmt.Errorf("adopting %s into release %q: %w", resource, releaseName, err)
+		}
+	}
+
+	return nil
+}
+
+func adoptForHelm3(runner Runner, namespace, kubeContext, releaseName, resource string) error {
+	klog.Infof("adopting %s into helm 3 release %q in namespace %q", resource, releaseName, namespace)
+
+	args := []string{"annotate", resource,
+		fmt.Sprintf("meta.helm.sh/release-name=%s", releaseName),
+		fmt.Sprintf("meta.helm.sh/release-namespace=%s", namespace),
+		"--overwrite",
+	}
+	args = append(args, kubectlContextArgs(namespace, kubeContext)...)
+	if _, err := runner.Run("kubectl", args...); err != nil {
+		return err
+	}
+
+	labelArgs := []string{"label", resource, "app.kubernetes.io/managed-by=Helm", "--overwrite"}
+	labelArgs = append(labelArgs, kubectlContextArgs(namespace, kubeContext)...)
+	_, err := runner.Run("kubectl", labelArgs...)
+	return err
+}
+
+func adoptForHelm2(runner Runner, tillerNamespace, namespace, kubeContext, releaseName, resource string) error {
+	klog.Infof("adopting %s into helm 2 release %q via tiller namespace %q", resource, releaseName, tillerNamespace)
+
+	args := []string{"label", resource, fmt.Sprintf("helm-x/adopted-into=%s", releaseName), "--overwrite"}
+	args = append(args, kubectlContextArgs(namespace, kubeContext)...)
+	_, err := runner.Run("kubectl", args...)
+	return err
+}
+
+// kubectlContextArgs turns a namespace/kubeContext pair into the
+// equivalent kubectl CLI flags, omitting either when empty.
+func kubectlContextArgs(namespace, kubeContext string) []string {
+	var args []string
+	if namespace != "" {
+		args = append(args, "--namespace", namespace)
+	}
+	if kubeContext != "" {
+		args = append(args, "--context", kubeContext)
+	}
+	return args
+}
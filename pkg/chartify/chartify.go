@@ -0,0 +1,260 @@
+package chartify
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mumoshu/helm-x/pkg/inject"
+	"github.com/mumoshu/helm-x/pkg/patch"
+
+	"k8s.io/klog"
+)
+
+// Chartify turns dir (a local helm chart, a directory of plain
+// Kubernetes manifests, or a kustomization) into a temporary, installable
+// Helm chart: manifests are rendered up front and the injectors in
+// opts.Injects, the patches and the post-renderer configured in opts are
+// applied to the rendered output, so the chart `helm upgrade --install`
+// eventually sees is already final. opts.Injectors and
+// opts.AdhocChartDependencies are not applied. The caller is responsible
+// for removing the returned directory.
+func Chartify(dir string, opts ChartifyOpts) (string, error) {
+	runner := opts.Runner
+	if runner == nil {
+		runner = DefaultRunner()
+	}
+
+	tempDir, err := ioutil.TempDir("", "helm-x-chartify")
+	if err != nil {
+		return "", fmt.Errorf("creating temp chart dir: %w", err)
+	}
+
+	manifests, err := render(dir, opts, runner)
+	if err != nil {
+		return "", fmt.Errorf("rendering %s: %w", dir, err)
+	}
+
+	manifests, err = inject.Inject(manifests, opts.Injects, runner.Run)
+	if err != nil {
+		return "", fmt.Errorf("injecting sidecars: %w", err)
+	}
+
+	manifests, err = patch.Patch(manifests, opts.JsonPatches, opts.StrategicMergePatches, runner.Run)
+	if err != nil {
+		return "", fmt.Errorf("patching manifests: %w", err)
+	}
+
+	manifests, err = postRender(manifests, opts, runner)
+	if err != nil {
+		return "", fmt.Errorf("post-rendering manifests: %w", err)
+	}
+
+	if err := writeChart(tempDir, dir, manifests, opts); err != nil {
+		return "", fmt.Errorf("writing temp chart: %w", err)
+	}
+
+	return tempDir, nil
+}
+
+// render produces the raw Kubernetes manifest YAML for dir, dispatching
+// on what kind of source it is.
+func render(dir string, opts ChartifyOpts, runner Runner) (string, error) {
+	switch {
+	case isKustomization(dir):
+		klog.Infof("rendering %s via kustomize build", dir)
+		return runner.Run("kustomize", "build", dir)
+	case isHelmChart(dir):
+		if opts.BuildDependencies || hasChartDependencies(dir) {
+			if err := buildChartDependencies(dir, runner); err != nil {
+				return "", fmt.Errorf("building chart dependencies: %w", err)
+			}
+		}
+		klog.Infof("rendering %s via helm template", dir)
+		return renderHelmChart(dir, opts, runner)
+	default:
+		klog.Infof("treating %s as a directory of plain manifests", dir)
+		return renderManifestsDir(dir)
+	}
+}
+
+func isKustomization(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "kustomization.yaml"))
+	return err == nil
+}
+
+func isHelmChart(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "Chart.yaml"))
+	return err == nil
+}
+
+// hasChartDependencies reports whether dir looks like a chart with
+// subchart dependencies that need building, either via a legacy
+// requirements.yaml or a `dependencies:` block in Chart.yaml.
+func hasChartDependencies(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, "requirements.yaml")); err == nil {
+		return true
+	}
+
+	chartYaml, err := ioutil.ReadFile(filepath.Join(dir, "Chart.yaml"))
+	if err != nil {
+		return false
+	}
+
+	return strings.Contains(string(chartYaml), "dependencies:")
+}
+
+// buildChartDependencies fetches dir's subchart dependencies into
+// charts/, preferring the reproducible `helm dependency build` (which
+// requires a Chart.lock/requirements.lock) and falling back to
+// `helm dependency update` when no lockfile exists yet.
+func buildChartDependencies(dir string, runner Runner) error {
+	lockFiles := []string{"Chart.lock", "requirements.lock"}
+
+	hasLock := false
+	for _, f := range lockFiles {
+		if _, err := os.Stat(filepath.Join(dir, f)); err == nil {
+			hasLock = true
+			break
+		}
+	}
+
+	verb := "build"
+	if !hasLock {
+		verb = "update"
+	}
+
+	klog.Infof("running helm dependency %s on %s", verb, dir)
+
+	_, err := runner.Run("helm", "dependency", verb, dir)
+	return err
+}
+
+func renderHelmChart(dir string, opts ChartifyOpts, runner Runner) (string, error) {
+	args := []string{"template", dir}
+
+	for _, f := range opts.ValuesFiles {
+		args = append(args, "--values", f)
+	}
+	for _, v := range opts.SetValues {
+		args = append(args, "--set", v)
+	}
+	if opts.ChartVersion != "" {
+		args = append(args, "--version", opts.ChartVersion)
+	}
+
+	return runner.Run("helm", args...)
+}
+
+// renderManifestsDir concatenates every *.yaml/*.yml file directly under
+// dir into a single multi-document manifest stream.
+func renderManifestsDir(dir string) (string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", dir, err)
+	}
+
+	var sb strings.Builder
+	for _, f := range files {
+		if f.IsDir() || (!strings.HasSuffix(f.Name(), ".yaml") && !strings.HasSuffix(f.Name(), ".yml")) {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return "", fmt.Errorf("reading %s: %w", f.Name(), err)
+		}
+
+		sb.WriteString("---\n")
+		sb.Write(content)
+		sb.WriteString("\n")
+	}
+
+	return sb.String(), nil
+}
+
+// writeChart lays manifests out as the sole template of a minimal chart
+// rooted at tempDir, with Chart.yaml's version/appVersion set from
+// opts.ChartVersion, provenance/user-supplied annotations recorded on
+// both Chart.yaml and values.yaml, and user-supplied labels recorded on
+// values.yaml.
+func writeChart(tempDir, sourceDir, manifests string, opts ChartifyOpts) error {
+	templatesDir := filepath.Join(tempDir, "templates")
+	if err := os.MkdirAll(templatesDir, 0755); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(templatesDir, "manifests.yaml"), []byte(manifests), 0644); err != nil {
+		return err
+	}
+
+	version := opts.ChartVersion
+	if version == "" {
+		version = "0.1.0"
+	}
+
+	annotations := provenanceAnnotations(sourceDir, opts, manifests)
+	for k, v := range opts.ExtraAnnotations {
+		annotations[k] = v
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("apiVersion: v1\nname: chartify\nversion: %s\nappVersion: %s\n", version, version))
+	sb.WriteString("annotations:\n")
+	for _, k := range sortedKeys(annotations) {
+		sb.WriteString(fmt.Sprintf("  %s: %q\n", k, annotations[k]))
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "Chart.yaml"), []byte(sb.String()), 0644); err != nil {
+		return err
+	}
+
+	var valuesSb strings.Builder
+	valuesSb.WriteString("chartify:\n  annotations:\n")
+	for _, k := range sortedKeys(annotations) {
+		valuesSb.WriteString(fmt.Sprintf("    %s: %q\n", k, annotations[k]))
+	}
+	if len(opts.ExtraLabels) > 0 {
+		valuesSb.WriteString("  labels:\n")
+		for _, k := range sortedKeys(opts.ExtraLabels) {
+			valuesSb.WriteString(fmt.Sprintf("    %s: %q\n", k, opts.ExtraLabels[k]))
+		}
+	}
+
+	return ioutil.WriteFile(filepath.Join(tempDir, "values.yaml"), []byte(valuesSb.String()), 0644)
+}
+
+// provenanceAnnotations records where a chartified chart came from, so
+// that operators can later trace a release back to the source directory
+// or upstream chart it was generated from.
+func provenanceAnnotations(sourceDir string, opts ChartifyOpts, manifests string) map[string]string {
+	annotations := map[string]string{
+		"helm-x/source":       sourceDir,
+		"helm-x/chart-digest": fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(manifests))),
+	}
+
+	if opts.ChartVersion != "" {
+		annotations["helm-x/chart-version"] = opts.ChartVersion
+	}
+	if len(opts.Injects) > 0 {
+		annotations["helm-x/injectors"] = strings.Join(opts.Injects, ",")
+	}
+	if len(opts.JsonPatches) > 0 || len(opts.StrategicMergePatches) > 0 {
+		annotations["helm-x/patches"] = strings.Join(append(append([]string{}, opts.JsonPatches...), opts.StrategicMergePatches...), ",")
+	}
+
+	return annotations
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
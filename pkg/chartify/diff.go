@@ -0,0 +1,28 @@
+package chartify
+
+import (
+	"fmt"
+)
+
+// Diff shows what `helm upgrade --install` would change, by shelling out
+// to the `helm-diff` plugin against opts.Chart (as produced by
+// Chartify).
+func Diff(opts DiffOpts) error {
+	args := []string{"diff", "upgrade", opts.ReleaseName, opts.Chart}
+
+	args = append(args, clientArgs(opts.ClientOpts)...)
+
+	var runner Runner
+	if opts.ChartifyOpts != nil {
+		runner = opts.ChartifyOpts.Runner
+	}
+
+	out, err := runnerOrDefault(runner).Run("helm", args...)
+	if err != nil {
+		return fmt.Errorf("diffing release %q: %w", opts.ReleaseName, err)
+	}
+
+	fmt.Fprint(opts.Out, out)
+
+	return nil
+}
@@ -0,0 +1,18 @@
+package chartify
+
+import (
+	"k8s.io/klog"
+)
+
+// postRender streams manifests to the stdin of opts.PostRenderer and
+// returns its stdout as the new manifests. When no post-renderer is
+// configured, manifests is returned unchanged.
+func postRender(manifests string, opts ChartifyOpts, runner Runner) (string, error) {
+	if opts.PostRenderer == "" {
+		return manifests, nil
+	}
+
+	klog.Infof("post-rendering manifests through %s %v", opts.PostRenderer, opts.PostRendererArgs)
+
+	return runner.RunWithStdin(manifests, opts.PostRenderer, opts.PostRendererArgs...)
+}
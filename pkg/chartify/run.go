@@ -0,0 +1,71 @@
+package chartify
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+)
+
+// Runner abstracts over shelling out to external binaries like helm and
+// kustomize, so tests and library callers (e.g. helmfile) can inject a
+// fake instead of spawning real processes.
+type Runner interface {
+	// Run executes name with args and returns its stdout.
+	Run(name string, args ...string) (string, error)
+	// RunWithStdin is like Run, but additionally streams stdin to the
+	// process (used by the post-renderer stage).
+	RunWithStdin(stdin, name string, args ...string) (string, error)
+}
+
+// execRunner is the default Runner: it really executes commands via
+// os/exec.
+type execRunner struct{}
+
+// DefaultRunner returns the Runner that shells out for real, used when
+// ChartifyOpts.Runner is left unset.
+func DefaultRunner() Runner {
+	return execRunner{}
+}
+
+// runnerOrDefault returns r, or DefaultRunner() when r is nil, so every
+// call site threading a possibly-unset Runner doesn't need to repeat the
+// nil check.
+func runnerOrDefault(r Runner) Runner {
+	if r == nil {
+		return DefaultRunner()
+	}
+	return r
+}
+
+func (execRunner) Run(name string, args ...string) (string, error) {
+	return runCmd(name, args...)
+}
+
+func (execRunner) RunWithStdin(stdin, name string, args ...string) (string, error) {
+	return runCmdWithStdin(stdin, name, args...)
+}
+
+// runCmd runs name with args, returning combined stdout as a string.
+// Stderr is captured and folded into the returned error so callers get
+// the actual helm/kustomize/kubectl failure message instead of just an
+// exit status.
+func runCmd(name string, args ...string) (string, error) {
+	return runCmdWithStdin("", name, args...)
+}
+
+func runCmdWithStdin(stdin, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	if stdin != "" {
+		cmd.Stdin = bytes.NewBufferString(stdin)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s %v: %w\n%s", name, args, err, stderr.String())
+	}
+
+	return stdout.String(), nil
+}
@@ -0,0 +1,114 @@
+package chartify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mumoshu/helm-x/pkg/release"
+)
+
+// Template renders the chart at dir (as produced by Chartify) with
+// `helm template` and writes the result to opts.Out. When
+// IncludeReleaseConfigmap/IncludeReleaseSecret is set, the manifest of
+// the release object that `helm upgrade --install` would otherwise
+// create is appended, so the output is a complete preview of what ends
+// up in the cluster.
+func Template(dir string, opts TemplateOpts) error {
+	args := []string{"template", dir, "--name", opts.ReleaseName}
+
+	if opts.ChartifyOpts != nil {
+		for _, f := range opts.ChartifyOpts.ValuesFiles {
+			args = append(args, "--values", f)
+		}
+		for _, v := range opts.ChartifyOpts.SetValues {
+			args = append(args, "--set", v)
+		}
+	}
+
+	var runner Runner
+	if opts.ChartifyOpts != nil {
+		runner = opts.ChartifyOpts.Runner
+	}
+
+	out, err := runnerOrDefault(runner).Run("helm", args...)
+	if err != nil {
+		return fmt.Errorf("templating %s: %w", dir, err)
+	}
+
+	if opts.IncludeReleaseConfigmap || opts.IncludeReleaseSecret {
+		releaseManifest, err := renderReleaseObject(opts)
+		if err != nil {
+			return fmt.Errorf("rendering release object: %w", err)
+		}
+		out += "---\n" + releaseManifest
+	}
+
+	if opts.Validate {
+		kubeContext := ""
+		if opts.ClientOpts != nil {
+			kubeContext = opts.ClientOpts.KubeContext
+		}
+		namespace := ""
+		if opts.ChartifyOpts != nil {
+			namespace = opts.ChartifyOpts.Namespace
+		}
+		if err := validate(out, kubeContext, namespace); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprint(opts.Out, out)
+
+	return nil
+}
+
+// renderReleaseObject builds the ConfigMap (Helm 2) or Secret (Helm 3)
+// that would otherwise be created by `helm upgrade --install`, so
+// `--include-release-configmap`/`--include-release-secret` can preview
+// it without touching the cluster.
+func renderReleaseObject(opts TemplateOpts) (string, error) {
+	extraLabels := map[string]string{}
+	extraAnnotations := ParseKeyValues(opts.ReleaseAnnotations)
+	for k, v := range ParseKeyValues(opts.ReleaseLabels) {
+		extraLabels[k] = v
+	}
+
+	var labelsYaml, annotationsYaml strings.Builder
+	for _, k := range sortedKeys(extraLabels) {
+		labelsYaml.WriteString(fmt.Sprintf("    %s: %q\n", k, extraLabels[k]))
+	}
+	for _, k := range sortedKeys(extraAnnotations) {
+		annotationsYaml.WriteString(fmt.Sprintf("    %s: %q\n", k, extraAnnotations[k]))
+	}
+
+	if opts.IncludeReleaseSecret {
+		namespace := ""
+		if opts.ChartifyOpts != nil {
+			namespace = opts.ChartifyOpts.Namespace
+		}
+		return fmt.Sprintf(`apiVersion: v1
+kind: Secret
+metadata:
+  name: %s
+  namespace: %s
+  labels:
+    owner: helm
+    name: %s
+    version: "1"
+%s  annotations:
+%stype: helm.sh/release.v1
+`, release.ReleaseSecretName(opts.ReleaseName, 1), namespace, opts.ReleaseName, labelsYaml.String(), annotationsYaml.String()), nil
+	}
+
+	return fmt.Sprintf(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %s.v1
+  namespace: %s
+  labels:
+    OWNER: TILLER
+    NAME: %s
+    VERSION: "1"
+%s  annotations:
+%s`, opts.ReleaseName, opts.TillerNamespace, opts.ReleaseName, labelsYaml.String(), annotationsYaml.String()), nil
+}
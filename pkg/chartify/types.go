@@ -0,0 +1,146 @@
+package chartify
+
+import "io"
+
+// ClientOpts holds the options needed to talk to a Kubernetes/Tiller/Helm
+// endpoint, shared by every subcommand that eventually shells out to helm
+// or the Kubernetes API.
+type ClientOpts struct {
+	TLS     bool
+	TLSCert string
+	TLSKey  string
+
+	KubeContext string
+
+	// HelmVersion pins the major Helm version to target ("2" or "3").
+	// When empty, it is autodetected by shelling out to `helm version`.
+	HelmVersion string
+}
+
+// ChartifyOpts customizes how a directory or chart is turned into a
+// temporary, installable Helm chart.
+type ChartifyOpts struct {
+	Injectors []string
+	Injects   []string
+
+	AdhocChartDependencies []string
+
+	JsonPatches           []string
+	StrategicMergePatches []string
+
+	// PostRenderer, when set, receives the concatenated rendered
+	// manifest YAML on stdin after injectors and patches have run, and
+	// its stdout replaces the manifests going forward. This mirrors
+	// Helm's own --post-renderer contract.
+	PostRenderer     string
+	PostRendererArgs []string
+
+	// ExtraAnnotations are merged with the provenance annotations
+	// Chartify records automatically (source directory, chart digest,
+	// upstream chart+version, applied injectors/patches) and written onto
+	// the generated Chart.yaml and values.yaml; ExtraLabels are written
+	// onto values.yaml. Both are also recorded on the eventual Helm
+	// release object by Upgrade.
+	ExtraAnnotations map[string]string
+	ExtraLabels      map[string]string
+
+	ValuesFiles []string
+	SetValues   []string
+
+	Namespace       string
+	TillerNamespace string
+	ChartVersion    string
+
+	// BuildDependencies makes Chartify run `helm dependency build` (or
+	// `helm dependency update` when neither Chart.lock nor
+	// requirements.lock is present) on a local
+	// chart before templating it, so charts with subchart dependencies
+	// don't require the caller to pre-run it manually.
+	BuildDependencies bool
+
+	// Runner overrides how Chartify shells out to helm/kustomize.
+	// Library callers can provide a fake for tests; the zero value
+	// shells out for real via DefaultRunner.
+	Runner Runner
+
+	Debug bool
+}
+
+// UpgradeOpts configures `helm-x apply`/`helm-x upgrade`.
+type UpgradeOpts struct {
+	*ChartifyOpts
+	*ClientOpts
+
+	Out io.Writer
+
+	ReleaseName string
+	Chart       string
+
+	Timeout int
+	DryRun  bool
+	Install bool
+	Debug   bool
+
+	Adopt []string
+
+	// ReleaseAnnotations/ReleaseLabels are "key=value" pairs recorded on
+	// the generated chart and, where Helm supports it, the release
+	// object itself, for tracking chartify provenance.
+	ReleaseAnnotations []string
+	ReleaseLabels      []string
+}
+
+// TemplateOpts configures `helm-x template`.
+type TemplateOpts struct {
+	*ChartifyOpts
+	*ClientOpts
+
+	Out io.Writer
+
+	ReleaseName     string
+	TillerNamespace string
+
+	IncludeReleaseConfigmap bool
+	IncludeReleaseSecret    bool
+
+	// Validate submits every rendered manifest to the API server as a
+	// server-side dry-run after rendering, to catch missing CRDs,
+	// admission-webhook rejections, and schema errors early.
+	Validate bool
+
+	// ReleaseAnnotations/ReleaseLabels are "key=value" pairs recorded on
+	// the generated chart and previewed release object, for tracking
+	// chartify provenance.
+	ReleaseAnnotations []string
+	ReleaseLabels      []string
+
+	Debug bool
+}
+
+// DiffOpts configures `helm-x diff`.
+type DiffOpts struct {
+	*ChartifyOpts
+	*ClientOpts
+
+	Out io.Writer
+
+	ReleaseName string
+	Chart       string
+
+	Debug bool
+}
+
+// AdoptOpts configures `helm-x adopt`.
+type AdoptOpts struct {
+	*ClientOpts
+
+	Out io.Writer
+
+	Namespace       string
+	TillerNamespace string
+
+	// Runner overrides how Adopt shells out to kubectl. Library callers
+	// can provide a fake for tests; the zero value shells out for real
+	// via DefaultRunner.
+	Runner Runner
+}
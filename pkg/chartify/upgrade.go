@@ -0,0 +1,167 @@
+package chartify
+
+import (
+	"fmt"
+
+	"github.com/mumoshu/helm-x/pkg/release"
+
+	"k8s.io/klog"
+)
+
+// Upgrade runs `helm upgrade` (or `helm upgrade --install` when
+// opts.Install is set) for opts.ReleaseName using the chart at
+// opts.Chart, which is expected to already be the temp chart produced by
+// Chartify. When opts.ChartifyOpts carries ExtraAnnotations/ExtraLabels,
+// they're recorded on the release object itself once the upgrade
+// succeeds, since neither `helm upgrade` nor `helm template` has a flag
+// that annotates/labels the release object (as opposed to the resources
+// it manages).
+func Upgrade(opts UpgradeOpts) error {
+	args := []string{"upgrade", opts.ReleaseName, opts.Chart}
+
+	if opts.Install {
+		args = append(args, "--install")
+	}
+	if opts.DryRun {
+		args = append(args, "--dry-run")
+	}
+	if opts.Timeout > 0 {
+		args = append(args, "--timeout", fmt.Sprintf("%d", opts.Timeout))
+	}
+	if opts.ChartifyOpts != nil && opts.ChartifyOpts.Namespace != "" {
+		args = append(args, "--namespace", opts.ChartifyOpts.Namespace)
+	}
+
+	args = append(args, clientArgs(opts.ClientOpts)...)
+
+	klog.Infof("running helm %v", args)
+
+	var runner Runner
+	if opts.ChartifyOpts != nil {
+		runner = opts.ChartifyOpts.Runner
+	}
+	runner = runnerOrDefault(runner)
+
+	out, err := runner.Run("helm", args...)
+	if err != nil {
+		return fmt.Errorf("upgrading release %q: %w", opts.ReleaseName, err)
+	}
+
+	fmt.Fprint(opts.Out, out)
+
+	if opts.DryRun {
+		return nil
+	}
+
+	if err := recordReleaseProvenance(opts, runner); err != nil {
+		return fmt.Errorf("recording provenance on release %q: %w", opts.ReleaseName, err)
+	}
+
+	return nil
+}
+
+// recordReleaseProvenance annotates/labels the underlying Helm release
+// object (a Tiller ConfigMap on Helm 2, a release Secret on Helm 3) with
+// opts.ChartifyOpts.ExtraAnnotations/ExtraLabels, the same way `adopt`
+// annotates/labels adopted resources.
+func recordReleaseProvenance(opts UpgradeOpts, runner Runner) error {
+	if opts.ChartifyOpts == nil {
+		return nil
+	}
+
+	annotations := opts.ChartifyOpts.ExtraAnnotations
+	labels := opts.ChartifyOpts.ExtraLabels
+	if len(annotations) == 0 && len(labels) == 0 {
+		return nil
+	}
+
+	helmVersion := ""
+	kubeContext := ""
+	if opts.ClientOpts != nil {
+		helmVersion = opts.ClientOpts.HelmVersion
+		kubeContext = opts.ClientOpts.KubeContext
+	}
+	if helmVersion == "" {
+		detected, err := release.DetectHelmVersion()
+		if err != nil {
+			return fmt.Errorf("autodetecting helm version: %w", err)
+		}
+		helmVersion = detected
+	}
+
+	storage, err := release.NewReleaseStorage(helmVersion, opts.ChartifyOpts.TillerNamespace, opts.ChartifyOpts.Namespace, kubeContext)
+	if err != nil {
+		return fmt.Errorf("opening release storage: %w", err)
+	}
+
+	r, err := storage.GetRelease(opts.ReleaseName)
+	if err != nil {
+		return fmt.Errorf("looking up release: %w", err)
+	}
+
+	var kind, name string
+	if helmVersion == "3" {
+		kind, name = "secret", release.ReleaseSecretName(opts.ReleaseName, r.Version)
+	} else {
+		kind, name = "configmap", release.ReleaseConfigMapName(opts.ReleaseName, r.Version)
+	}
+
+	klog.Infof("recording provenance on %s %s/%s", kind, r.Namespace, name)
+
+	return annotateAndLabelReleaseObject(runner, kind, name, r.Namespace, kubeContext, annotations, labels)
+}
+
+// annotateAndLabelReleaseObject applies annotations/labels to the given
+// release storage object via kubectl, mirroring how adoptForHelm2/
+// adoptForHelm3 tag adopted resources.
+func annotateAndLabelReleaseObject(runner Runner, kind, name, namespace, kubeContext string, annotations, labels map[string]string) error {
+	if len(annotations) > 0 {
+		args := []string{"annotate", kind, name}
+		for _, k := range sortedKeys(annotations) {
+			args = append(args, fmt.Sprintf("%s=%s", k, annotations[k]))
+		}
+		args = append(args, "--overwrite")
+		args = append(args, kubectlContextArgs(namespace, kubeContext)...)
+		if _, err := runner.Run("kubectl", args...); err != nil {
+			return err
+		}
+	}
+
+	if len(labels) > 0 {
+		args := []string{"label", kind, name}
+		for _, k := range sortedKeys(labels) {
+			args = append(args, fmt.Sprintf("%s=%s", k, labels[k]))
+		}
+		args = append(args, "--overwrite")
+		args = append(args, kubectlContextArgs(namespace, kubeContext)...)
+		if _, err := runner.Run("kubectl", args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// clientArgs turns a ClientOpts into the equivalent helm CLI flags.
+func clientArgs(opts *ClientOpts) []string {
+	if opts == nil {
+		return nil
+	}
+
+	var args []string
+
+	if opts.TLS {
+		args = append(args, "--tls")
+	}
+	if opts.TLSCert != "" {
+		args = append(args, "--tls-cert", opts.TLSCert)
+	}
+	if opts.TLSKey != "" {
+		args = append(args, "--tls-key", opts.TLSKey)
+	}
+	if opts.KubeContext != "" {
+		args = append(args, "--kube-context", opts.KubeContext)
+	}
+
+	return args
+}
@@ -0,0 +1,22 @@
+package chartify
+
+import (
+	"strings"
+)
+
+// ParseKeyValues turns a list of "key=value" strings, as accepted by
+// repeatable flags like --release-annotation, into a map. Entries
+// without an "=" are ignored.
+func ParseKeyValues(pairs []string) map[string]string {
+	result := map[string]string{}
+
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		result[kv[0]] = kv[1]
+	}
+
+	return result
+}
@@ -0,0 +1,131 @@
+package chartify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mumoshu/helm-x/pkg/release"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+)
+
+// fieldManager identifies helm-x's server-side apply dry-runs to the API
+// server, the same way `kubectl apply` identifies itself as "kubectl-client-side-apply".
+const fieldManager = "helm-x"
+
+// validationError collects one diagnostic per manifest so callers see
+// every failure in a rendered chart, not just the first one.
+type validationError struct {
+	failures []string
+}
+
+func (e *validationError) Error() string {
+	return fmt.Sprintf("%d manifest(s) failed server-side validation:\n%s", len(e.failures), strings.Join(e.failures, "\n"))
+}
+
+// validate submits each document in manifests to the API server as a
+// server-side dry-run, using kubeContext to select the cluster and
+// namespace as the default namespace for manifests that don't set their
+// own (mirroring how `helm template --namespace`/`helm upgrade --install
+// --namespace` behaves). It returns a validationError listing every
+// manifest that failed, rather than stopping at the first one.
+func validate(manifests, kubeContext, namespace string) error {
+	restConfig, err := release.RESTConfigForContext(kubeContext)
+	if err != nil {
+		return fmt.Errorf("loading kubeconfig: %w", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("creating dynamic client: %w", err)
+	}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("creating discovery client: %w", err)
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+
+	var failures []string
+	for i, doc := range strings.Split(manifests, "\n---\n") {
+		doc = strings.TrimSpace(doc)
+		if doc == "" {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(doc), &obj.Object); err != nil {
+			failures = append(failures, fmt.Sprintf("document %d: parsing manifest: %v", i, err))
+			continue
+		}
+		if obj.GetKind() == "" {
+			continue
+		}
+
+		if err := dryRunApply(dynamicClient, mapper, obj, namespace); err != nil {
+			failures = append(failures, fmt.Sprintf("%s %q: %v", obj.GetKind(), obj.GetName(), err))
+			continue
+		}
+
+		klog.Infof("validated %s %q", obj.GetKind(), obj.GetName())
+	}
+
+	if len(failures) > 0 {
+		return &validationError{failures: failures}
+	}
+
+	return nil
+}
+
+func dryRunApply(client dynamic.Interface, mapper meta.RESTMapper, obj *unstructured.Unstructured, defaultNamespace string) error {
+	gvk := obj.GroupVersionKind()
+
+	restMapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return fmt.Errorf("no matching CRD/API resource registered for %s: %w", gvk, err)
+	}
+
+	var resource dynamic.ResourceInterface
+	if restMapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		namespace := obj.GetNamespace()
+		if namespace == "" {
+			namespace = defaultNamespace
+		}
+		if namespace == "" {
+			namespace = "default"
+		}
+		resource = client.Resource(restMapping.Resource).Namespace(namespace)
+	} else {
+		resource = client.Resource(restMapping.Resource)
+	}
+
+	// A plain dry-run Create errors with AlreadyExists for every resource
+	// that's already applied to the cluster, which is the normal case
+	// when iterating on a chart that's already installed. Server-side
+	// apply is idempotent: it merges with the live object instead of
+	// erroring when one already exists, matching what `kubectl apply`
+	// actually does.
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return fmt.Errorf("marshalling manifest: %w", err)
+	}
+
+	force := true
+	_, err = resource.Patch(context.TODO(), obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+		DryRun:       []string{metav1.DryRunAll},
+		Force:        &force,
+		FieldManager: fieldManager,
+	})
+	return err
+}
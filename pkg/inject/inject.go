@@ -0,0 +1,63 @@
+// Package inject implements helm-x's sidecar-injection stage: piping
+// rendered manifests through externally configured injector binaries
+// like istioctl.
+package inject
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Run shells out to name with args, returning its stdout. Implemented by
+// chartify.Runner so callers can inject a fake for tests.
+type Run func(name string, args ...string) (string, error)
+
+// Inject runs each configured injector command over manifests, replacing
+// "FILE" in its argument list with the path to a temp file holding the
+// manifests, and taking the command's stdout as the new manifests. When
+// no injectors are configured, manifests is returned unchanged.
+func Inject(manifests string, specs []string, run Run) (string, error) {
+	if len(specs) == 0 {
+		return manifests, nil
+	}
+
+	for _, spec := range specs {
+		injected, err := runInjector(spec, manifests, run)
+		if err != nil {
+			return "", fmt.Errorf("running injector %q: %w", spec, err)
+		}
+		manifests = injected
+	}
+
+	return manifests, nil
+}
+
+// runInjector runs a single "CMD ARG1 ARG2" injector spec, substituting
+// FILE with a temp file containing manifests.
+func runInjector(spec, manifests string, run Run) (string, error) {
+	f, err := ioutil.TempFile("", "helm-x-inject")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(manifests); err != nil {
+		f.Close()
+		return "", err
+	}
+	f.Close()
+
+	fields := strings.Fields(spec)
+	for i, field := range fields {
+		if field == "FILE" {
+			fields[i] = f.Name()
+		}
+	}
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty injector spec")
+	}
+
+	return run(fields[0], fields[1:]...)
+}
@@ -0,0 +1,56 @@
+// Package patch implements helm-x's manifest-patching stage: applying
+// Kustomize JSON Patch / Strategic Merge Patch files to already-rendered
+// manifests.
+package patch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Run shells out to name with args, returning its stdout. Implemented by
+// chartify.Runner so callers can inject a fake for tests.
+type Run func(name string, args ...string) (string, error)
+
+// Patch applies any configured JSON Patch / Strategic Merge Patch files
+// to manifests via `kustomize build` over a throwaway kustomization that
+// treats manifests as its base. When no patches are configured,
+// manifests is returned unchanged.
+func Patch(manifests string, jsonPatches, strategicMergePatches []string, run Run) (string, error) {
+	if len(jsonPatches) == 0 && len(strategicMergePatches) == 0 {
+		return manifests, nil
+	}
+
+	dir, err := ioutil.TempDir("", "helm-x-patch")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	baseFile := filepath.Join(dir, "base.yaml")
+	if err := ioutil.WriteFile(baseFile, []byte(manifests), 0644); err != nil {
+		return "", err
+	}
+
+	kustomization := "resources:\n- base.yaml\n"
+	if len(jsonPatches) > 0 {
+		kustomization += "patchesJson6902:\n"
+		for _, p := range jsonPatches {
+			kustomization += fmt.Sprintf("- path: %s\n  target: {}\n", p)
+		}
+	}
+	if len(strategicMergePatches) > 0 {
+		kustomization += "patchesStrategicMerge:\n"
+		for _, p := range strategicMergePatches {
+			kustomization += fmt.Sprintf("- %s\n", p)
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(kustomization), 0644); err != nil {
+		return "", err
+	}
+
+	return run("kustomize", "build", dir)
+}
@@ -0,0 +1,35 @@
+package release
+
+import (
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// newClientset builds a Kubernetes clientset from the local kubeconfig,
+// optionally overriding the context (an empty kubeContext uses
+// kubeconfig's current-context).
+func newClientset(kubeContext string) (kubernetes.Interface, error) {
+	config, err := RESTConfigForContext(kubeContext)
+	if err != nil {
+		return nil, err
+	}
+
+	return kubernetes.NewForConfig(config)
+}
+
+// RESTConfigForContext loads a REST config from the local kubeconfig,
+// optionally overriding the context (an empty kubeContext uses
+// kubeconfig's current-context). Exported so other packages needing a
+// Kubernetes client (e.g. chartify's --validate dry-run) don't each
+// reimplement kubeconfig loading.
+func RESTConfigForContext(kubeContext string) (*rest.Config, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+
+	overrides := &clientcmd.ConfigOverrides{}
+	if kubeContext != "" {
+		overrides.CurrentContext = kubeContext
+	}
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+}
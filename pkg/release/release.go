@@ -0,0 +1,229 @@
+package release
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	hapirelease "k8s.io/helm/pkg/proto/hapi/release"
+
+	helm3release "helm.sh/helm/v3/pkg/release"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Release is helm-x's storage-agnostic view of a Helm release, populated
+// from either a Tiller ConfigMap (Helm 2) or a release Secret (Helm 3).
+type Release struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Manifest  string `json:"manifest"`
+	Config    string `json:"config,omitempty"`
+}
+
+// Storage abstracts over where a Helm release object lives, so that
+// commands like `dump` and `adopt` don't need to know whether they're
+// talking to Helm 2's Tiller ConfigMaps or Helm 3's release Secrets.
+type Storage interface {
+	// GetRelease returns the latest revision of the named release.
+	GetRelease(name string) (*Release, error)
+}
+
+// configMapsStorage reads Helm 2 (Tiller) releases, which are stored as
+// ConfigMaps named after the release in the Tiller namespace.
+type configMapsStorage struct {
+	clientset       kubernetes.Interface
+	tillerNamespace string
+}
+
+// NewConfigMapsStorage returns a Storage backed by Tiller's ConfigMap
+// release store in tillerNamespace, talking to the cluster selected by
+// kubeContext (an empty kubeContext uses kubeconfig's current-context).
+func NewConfigMapsStorage(tillerNamespace, kubeContext string) (Storage, error) {
+	clientset, err := newClientset(kubeContext)
+	if err != nil {
+		return nil, fmt.Errorf("creating kubernetes client: %w", err)
+	}
+
+	return &configMapsStorage{clientset: clientset, tillerNamespace: tillerNamespace}, nil
+}
+
+func (s *configMapsStorage) GetRelease(name string) (*Release, error) {
+	cms, err := s.clientset.CoreV1().ConfigMaps(s.tillerNamespace).List(context.TODO(), v1.ListOptions{
+		LabelSelector: fmt.Sprintf("OWNER=TILLER,NAME=%s", name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing tiller release configmaps: %w", err)
+	}
+
+	var latest *corev1.ConfigMap
+	latestVersion := -1
+	for i := range cms.Items {
+		cm := &cms.Items[i]
+		version, _ := strconv.Atoi(cm.Labels["VERSION"])
+		if version > latestVersion {
+			latest, latestVersion = cm, version
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("no tiller release configmap found for %q in namespace %q", name, s.tillerNamespace)
+	}
+
+	payload, err := decodeReleasePayload(latest.Data["release"])
+	if err != nil {
+		return nil, fmt.Errorf("decoding release %q: %w", name, err)
+	}
+
+	var rel hapirelease.Release
+	if err := json.Unmarshal(payload, &rel); err != nil {
+		return nil, fmt.Errorf("unmarshalling tiller release %q: %w", name, err)
+	}
+
+	return &Release{
+		Name:      name,
+		Namespace: s.tillerNamespace,
+		Version:   latestVersion,
+		Manifest:  rel.Manifest,
+	}, nil
+}
+
+// secretsStorage reads Helm 3 releases, which are stored as Secrets
+// named "sh.helm.release.v1.<name>.v<rev>" in the release's own
+// namespace (Helm 3 has no Tiller, so there is no separate server
+// namespace to plumb through).
+type secretsStorage struct {
+	clientset kubernetes.Interface
+	namespace string
+}
+
+// NewSecretsStorage returns a Storage backed by Helm 3's Secret release
+// store in namespace, which is the namespace the release itself lives
+// in (not a Tiller namespace), talking to the cluster selected by
+// kubeContext (an empty kubeContext uses kubeconfig's current-context).
+func NewSecretsStorage(namespace, kubeContext string) (Storage, error) {
+	clientset, err := newClientset(kubeContext)
+	if err != nil {
+		return nil, fmt.Errorf("creating kubernetes client: %w", err)
+	}
+
+	return &secretsStorage{clientset: clientset, namespace: namespace}, nil
+}
+
+func (s *secretsStorage) GetRelease(name string) (*Release, error) {
+	secrets, err := s.clientset.CoreV1().Secrets(s.namespace).List(context.TODO(), v1.ListOptions{
+		LabelSelector: fmt.Sprintf("owner=helm,name=%s", name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing helm 3 release secrets: %w", err)
+	}
+
+	var latest *corev1.Secret
+	latestVersion := -1
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		version, _ := strconv.Atoi(secret.Labels["version"])
+		if version > latestVersion {
+			latest, latestVersion = secret, version
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("no release secret found for %q in namespace %q, expected a name like %q", name, s.namespace, ReleaseSecretName(name, 1))
+	}
+
+	payload, err := decodeReleasePayload(string(latest.Data["release"]))
+	if err != nil {
+		return nil, fmt.Errorf("decoding release %q: %w", name, err)
+	}
+
+	var rel helm3release.Release
+	if err := json.Unmarshal(payload, &rel); err != nil {
+		return nil, fmt.Errorf("unmarshalling helm 3 release %q: %w", name, err)
+	}
+
+	return &Release{
+		Name:      name,
+		Namespace: s.namespace,
+		Version:   latestVersion,
+		Manifest:  rel.Manifest,
+	}, nil
+}
+
+// ReleaseSecretName builds the Helm 3 release secret name for a given
+// release name and revision, e.g. "sh.helm.release.v1.myapp.v3".
+func ReleaseSecretName(name string, revision int) string {
+	return fmt.Sprintf("sh.helm.release.v1.%s.v%d", name, revision)
+}
+
+// ReleaseConfigMapName builds the Helm 2/Tiller release configmap name
+// for a given release name and revision, e.g. "myapp.v3".
+func ReleaseConfigMapName(name string, revision int) string {
+	return fmt.Sprintf("%s.v%d", name, revision)
+}
+
+// decodeReleasePayload reverses the base64+gzip encoding Helm uses to
+// store both Tiller ConfigMap and Helm 3 Secret release payloads.
+func decodeReleasePayload(encoded string) ([]byte, error) {
+	b64Decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("base64-decoding release payload: %w", err)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(b64Decoded))
+	if err != nil {
+		return nil, fmt.Errorf("creating gzip reader for release payload: %w", err)
+	}
+	defer gz.Close()
+
+	return ioutil.ReadAll(gz)
+}
+
+// DetectHelmVersion shells out to `helm version` and returns "2" or "3"
+// depending on the client reported. Used when --helm-version is left
+// unset.
+func DetectHelmVersion() (string, error) {
+	out, err := runCmd("helm", "version", "--short", "--client")
+	if err != nil {
+		return "", fmt.Errorf("running helm version: %w", err)
+	}
+
+	switch {
+	case strings.HasPrefix(strings.TrimSpace(out), "v3"):
+		return "3", nil
+	case strings.HasPrefix(strings.TrimSpace(out), "Client: v2"), strings.Contains(out, "SemVer:\"v2"):
+		return "2", nil
+	default:
+		return "", fmt.Errorf("unable to determine helm major version from %q", out)
+	}
+}
+
+// NewReleaseStorage picks the ConfigMaps (Helm 2) or Secrets (Helm 3)
+// backed Storage, autodetecting the Helm version via `helm version` when
+// helmVersion is empty, and talking to the cluster selected by
+// kubeContext (an empty kubeContext uses kubeconfig's current-context).
+func NewReleaseStorage(helmVersion, tillerNamespace, releaseNamespace, kubeContext string) (Storage, error) {
+	if helmVersion == "" {
+		detected, err := DetectHelmVersion()
+		if err != nil {
+			return nil, fmt.Errorf("autodetecting helm version: %w", err)
+		}
+		helmVersion = detected
+	}
+
+	switch helmVersion {
+	case "2":
+		return NewConfigMapsStorage(tillerNamespace, kubeContext)
+	case "3":
+		return NewSecretsStorage(releaseNamespace, kubeContext)
+	default:
+		return nil, fmt.Errorf("unsupported helm version %q, expected \"2\" or \"3\"", helmVersion)
+	}
+}
@@ -0,0 +1,24 @@
+package release
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+)
+
+// runCmd runs name with args, returning combined stdout as a string.
+// Stderr is captured and folded into the returned error so callers get
+// the actual helm failure message instead of just an exit status.
+func runCmd(name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s %v: %w\n%s", name, args, err, stderr.String())
+	}
+
+	return stdout.String(), nil
+}